@@ -12,10 +12,7 @@ import (
 	"github.com/foggyco/x/abif"
 )
 
-var (
-	printValues  = flag.Bool("val", true, "print values")
-	useKnownTags = flag.Bool("k", true, "use known tags to interpret data")
-)
+var printValues = flag.Bool("val", true, "print values")
 
 func main() {
 	flag.Parse()
@@ -40,17 +37,6 @@ func main() {
 			fmt.Printf("%s: %v\n", t, err)
 		} else {
 			if *printValues {
-				if *useKnownTags {
-					switch string(t.Name[:]) {
-					case "APrX", "PBAS", "RMdX", "FWO_":
-						c := v.([]int8)
-						b := make([]byte, len(c))
-						for i := range c {
-							b[i] = byte(c[i])
-						}
-						v = string(b)
-					}
-				}
 				// PBAS 1/2: seq characters edited by user, basecaller
 				// PCON 1/2: quality values ...
 				// PLOC 1/2: peak locations ...
@@ -0,0 +1,58 @@
+package abif
+
+// goType identifies the Go type a well-known tag's value should be
+// presented as, which is occasionally not what its raw ElemType would
+// naively decode to.
+type goType int
+
+const (
+	goTypeUnknown goType = iota
+	goTypeString         // a char array (ElemType 2) holding ASCII text, e.g. a base sequence
+)
+
+// knownTags maps the names of well-known ABIF tags to the Go type their
+// value should be presented as. Tag numbers are not distinguished: every
+// known tag that comes in multiple numbered instances (PBAS 1/2, PCON
+// 1/2, PLOC 1/2, DATA 1-12, ...) shares the same type across instances.
+//
+// This is not an exhaustive list of the tags defined by the spec, just
+// the ones in common use. See the spec for the full tag dictionary.
+var knownTags = map[[4]byte]goType{
+	tagName("PBAS"): goTypeString, // basecalled sequence
+	tagName("PCON"): goTypeUnknown,
+	tagName("PLOC"): goTypeUnknown,
+	tagName("FWO_"): goTypeString, // base order of the four trace channels, e.g. "GATC"
+	tagName("APrX"): goTypeString, // base order used during primer/mobility processing
+	tagName("RMdX"): goTypeString, // mobility file name
+	tagName("DATA"): goTypeUnknown,
+}
+
+func tagName(name string) (t [4]byte) {
+	copy(t[:], name)
+	return t
+}
+
+// coerceKnownType adjusts v, the value decoded from t's raw ElemType, to
+// match the Go type registered for t in knownTags, if any. A
+// single-element char tag decodes as a bare int8 rather than []int8
+// (see decodeValue), so that case is coerced too.
+func coerceKnownType(t Tag, v interface{}) interface{} {
+	switch knownTags[t.Name] {
+	case goTypeString:
+		switch b := v.(type) {
+		case []int8:
+			return int8sToString(b)
+		case int8:
+			return int8sToString([]int8{b})
+		}
+	}
+	return v
+}
+
+func int8sToString(b []int8) string {
+	buf := make([]byte, len(b))
+	for i, c := range b {
+		buf[i] = byte(c)
+	}
+	return string(buf)
+}
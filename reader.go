@@ -8,15 +8,22 @@
 package abif
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"math"
+	"sort"
+	"sync"
 	"time"
 )
 
+// A Reader reads tag values from src using ReadAt, so that it is safe
+// for concurrent use by multiple goroutines: unlike Seek+Read, ReadAt
+// does not require threading a shared cursor through Reader.
 type Reader struct {
-	src  io.ReadSeeker
+	src  io.ReaderAt
+	size int64 // file size, if known; -1 otherwise
 	refs map[Tag]ref
 }
 
@@ -51,7 +58,7 @@ func NewTag(name string, num int32) Tag {
 // this is slightly wasteful.
 type ref struct {
 	ElemType int16 // for strings, an element is a byte, not the string itself
-	_        int16 // ElemSize, unused
+	ElemSize int16 // size of one element in bytes; only checked in strict mode
 	NElem    int32
 	DataSize int32
 	Data     [4]byte // the data itself if DataSize <= 4, otherwise offset to data in file, interpreted as BigEndian int32
@@ -66,22 +73,81 @@ type entry struct {
 	Ref ref
 }
 
+// fileHeader is an ABIF file's fixed-size header: magic, version, and
+// the root directory entry, which locates the rest of the directory.
+type fileHeader struct {
+	Magic   [4]byte
+	Version uint16
+	Dir     entry
+}
+
+// headerLen is the size, in bytes, of fileHeader.
+const headerLen = 4 + 2 + 28
+
+// Options configures optional Reader behavior.
+type Options struct {
+	// Strict makes NewReaderOptions/NewReaderAtOptions validate more than
+	// the bare minimum needed to read tag values: element-size
+	// consistency between a tag's declared ElemType and ElemSize,
+	// in-bounds and non-overlapping directory and value offsets, and a
+	// plausible version number. A file that fails strict validation
+	// would otherwise often still produce usable values from Value; use
+	// Strict when that risk is unacceptable, e.g. when processing files
+	// from an untrusted source.
+	Strict bool
+}
+
+// NewReader returns a Reader that reads tag values from src.
+//
+// src is wrapped in a small adapter that serializes access with a
+// mutex, since a shared seek cursor isn't safe for concurrent use.
+// Callers with random access to the underlying data (a file, an
+// in-memory buffer, ...) should use NewReaderAt instead.
 func NewReader(src io.ReadSeeker) (*Reader, error) {
-	r := Reader{
-		src: src,
+	return NewReaderOptions(src, Options{})
+}
+
+// NewReaderOptions is like NewReader, with Options controlling optional
+// behavior.
+func NewReaderOptions(src io.ReadSeeker, opts Options) (*Reader, error) {
+	size := int64(-1)
+	if opts.Strict {
+		end, err := src.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		size = end
 	}
+	return newReader(&seekerReaderAt{rs: src}, size, opts)
+}
+
+// NewReaderAt returns a Reader that reads tag values from src, which has
+// size bytes. Unlike NewReader, the Reader returned by NewReaderAt is
+// safe to share across goroutines without any locking on the caller's
+// part: every read is a single independent ReadAt call.
+func NewReaderAt(src io.ReaderAt, size int64) (*Reader, error) {
+	return NewReaderAtOptions(src, size, Options{})
+}
+
+// NewReaderAtOptions is like NewReaderAt, with Options controlling
+// optional behavior.
+func NewReaderAtOptions(src io.ReaderAt, size int64, opts Options) (*Reader, error) {
+	return newReader(src, size, opts)
+}
+
+func newReader(src io.ReaderAt, size int64, opts Options) (*Reader, error) {
+	r := &Reader{src: src, size: size}
 
-	// seek to beginning
-	if _, err := src.Seek(0, 0); err != nil {
+	// read and parse the header: magic, version, and root directory entry
+	headerBuf := make([]byte, headerLen)
+	if _, err := src.ReadAt(headerBuf, 0); err != nil {
 		return nil, err
 	}
-	// read and parse header
-	var header struct {
-		Magic   [4]byte
-		Version uint16
-		Dir     entry
-	}
-	if err := binary.Read(src, binary.BigEndian, &header); err != nil {
+	var header fileHeader
+	if err := binary.Read(bytes.NewReader(headerBuf), binary.BigEndian, &header); err != nil {
 		return nil, err
 	}
 	// validate header
@@ -92,22 +158,115 @@ func NewReader(src io.ReadSeeker) (*Reader, error) {
 		return nil, fmt.Errorf("unknown version %d", header.Version)
 	}
 
-	// seek and read the directory
-	if _, err := src.Seek(header.Dir.Ref.dataOffset(), 0); err != nil {
+	// read and parse the directory
+	//
+	// NElem comes straight from the file and must be bounds-checked
+	// before it drives an allocation or a read size: an adversarial or
+	// corrupt value (e.g. NElem near 1<<31) would otherwise make(...)
+	// an enormous slice and crash the process with an unrecoverable
+	// out-of-memory fatal error, before validateStrict ever runs.
+	nElem := header.Dir.Ref.NElem
+	if nElem < 0 {
+		return nil, fmt.Errorf("bad directory: NElem %d < 0", nElem)
+	}
+	const maxDirEntries = 1 << 20 // far more than any real ABIF file has; just a sanity cap
+	if nElem > maxDirEntries {
+		return nil, fmt.Errorf("bad directory: NElem %d exceeds sanity limit %d", nElem, maxDirEntries)
+	}
+	dirSize := int64(nElem) * 28
+	if size >= 0 {
+		dirStart := header.Dir.Ref.dataOffset()
+		if dirStart < 0 || dirStart+dirSize > size {
+			return nil, fmt.Errorf("bad directory: data at %d..%d is out of bounds for a %d-byte file", dirStart, dirStart+dirSize, size)
+		}
+	}
+	dirBuf := make([]byte, dirSize)
+	if _, err := src.ReadAt(dirBuf, header.Dir.Ref.dataOffset()); err != nil {
 		return nil, err
 	}
-
-	entries := make([]entry, header.Dir.Ref.NElem)
-	if err := binary.Read(src, binary.BigEndian, &entries); err != nil {
+	entries := make([]entry, nElem)
+	if err := binary.Read(bytes.NewReader(dirBuf), binary.BigEndian, &entries); err != nil {
 		return nil, err
 	}
 
+	if opts.Strict {
+		if err := validateStrict(header, entries, size); err != nil {
+			return nil, err
+		}
+	}
+
 	r.refs = make(map[Tag]ref, len(entries))
 	for _, e := range entries {
 		r.refs[e.Tag] = e.Ref
 	}
 
-	return &r, nil
+	return r, nil
+}
+
+// validateStrict performs the extra validation documented on
+// Options.Strict. It catches files that Value could otherwise silently
+// misinterpret instead of erroring on: a corrupt ElemSize, directory
+// entries pointing outside the file, out-of-line values that overlap
+// each other, and an implausible version.
+func validateStrict(header fileHeader, entries []entry, size int64) error {
+	if header.Version < 100 || header.Version > 103 {
+		return fmt.Errorf("strict: implausible version %d", header.Version)
+	}
+
+	type region struct {
+		tag        Tag
+		start, end int64
+	}
+	var regions []region
+	checkRef := func(t Tag, x ref) error {
+		if int(x.ElemType) < len(dataTypes) {
+			if want := dataTypes[x.ElemType].size; want != 0 && int(x.ElemSize) != want {
+				return fmt.Errorf("strict: tag %s: element size %d does not match element type %d (want %d)", t, x.ElemSize, x.ElemType, want)
+			}
+		}
+		if x.DataSize > 4 {
+			start := x.dataOffset()
+			end := start + int64(x.DataSize)
+			if start < 0 || (size >= 0 && end > size) {
+				return fmt.Errorf("strict: tag %s: data at %d..%d is out of bounds for a %d-byte file", t, start, end, size)
+			}
+			regions = append(regions, region{t, start, end})
+		}
+		return nil
+	}
+
+	if err := checkRef(NewTag("tdir", 1), header.Dir.Ref); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := checkRef(e.Tag, e.Ref); err != nil {
+			return err
+		}
+	}
+
+	sort.Slice(regions, func(i, j int) bool { return regions[i].start < regions[j].start })
+	for i := 1; i < len(regions); i++ {
+		if regions[i].start < regions[i-1].end {
+			return fmt.Errorf("strict: tag %s data overlaps tag %s", regions[i].tag, regions[i-1].tag)
+		}
+	}
+	return nil
+}
+
+// seekerReaderAt adapts an io.ReadSeeker to an io.ReaderAt by serializing
+// access to the shared seek cursor with a mutex.
+type seekerReaderAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+}
+
+func (s *seekerReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(s.rs, p)
 }
 
 // Tags returns a list of the tags available in the file.
@@ -121,23 +280,42 @@ func (r *Reader) Tags() []Tag {
 
 type (
 	errNotFound Tag
-	errBadValue Tag
-	errBadType  struct {
+	errBadValue struct {
+		Tag
+		reason string
+	}
+	errBadType struct {
 		Tag
 		ref
 	}
+	errWrongType struct {
+		Tag
+		want string
+		got  interface{}
+	}
 )
 
 func (t errNotFound) Error() string { return fmt.Sprintf("tag not found: %s", Tag(t)) }
-func (t errBadValue) Error() string { return fmt.Sprintf("malformed value for tag: %s", Tag(t)) }
+func (e errBadValue) Error() string {
+	return fmt.Sprintf("malformed value for tag %s: %s", e.Tag, e.reason)
+}
 func (e errBadType) Error() string {
 	return fmt.Sprintf("unknown value type for tag %s: %d", e.Tag, e.ref.ElemType)
 }
+func (e errWrongType) Error() string {
+	return fmt.Sprintf("tag %s: want %s, got %T", e.Tag, e.want, e.got)
+}
 
-// TODO: The Value API could cause lots of seeking; on a large file, this could be bad for performance.
-// Consider offering an "AllValues()" or "Values(tt []Tag)" API that does sequential file reads.
+func isNotFound(err error) bool {
+	_, ok := err.(errNotFound)
+	return ok
+}
 
 // Value reads the value identified by t.
+//
+// Reading many tags this way issues one ReadAt call per tag; on a large
+// file, with a slow or seek-heavy src (see NewReader), this can be bad
+// for performance. AllValues and Values batch reads across tags instead.
 func (r *Reader) Value(t Tag) (interface{}, error) {
 	x, ok := r.refs[t]
 	if !ok {
@@ -148,15 +326,71 @@ func (r *Reader) Value(t Tag) (interface{}, error) {
 	if x.DataSize <= 4 {
 		data = x.Data[:x.DataSize]
 	} else {
-		if _, err := r.src.Seek(x.dataOffset(), 0); err != nil {
+		data = make([]byte, x.DataSize)
+		if _, err := r.src.ReadAt(data, x.dataOffset()); err != nil {
 			return nil, err
 		}
-		data = make([]byte, x.DataSize)
-		if _, err := io.ReadFull(r.src, data); err != nil {
+	}
+
+	return decodeValue(t, x, data)
+}
+
+// AllValues reads every tag's value, as Value would, but reads the
+// out-of-line values in increasing offset order rather than tag order.
+func (r *Reader) AllValues() (map[Tag]interface{}, error) {
+	return r.Values(r.Tags())
+}
+
+// Values reads the values for tags, as Value would, but reads the
+// out-of-line values among tags in increasing offset order rather than
+// tag order. This matters when src is backed by a seek cursor (see
+// NewReader) or a medium, such as spinning disk, where sequential access
+// is cheaper than random access.
+func (r *Reader) Values(tags []Tag) (map[Tag]interface{}, error) {
+	type want struct {
+		tag Tag
+		ref ref
+	}
+	var inline, outOfLine []want
+	for _, t := range tags {
+		x, ok := r.refs[t]
+		if !ok {
+			return nil, errNotFound(t)
+		}
+		if x.DataSize <= 4 {
+			inline = append(inline, want{t, x})
+		} else {
+			outOfLine = append(outOfLine, want{t, x})
+		}
+	}
+	sort.Slice(outOfLine, func(i, j int) bool {
+		return outOfLine[i].ref.dataOffset() < outOfLine[j].ref.dataOffset()
+	})
+
+	values := make(map[Tag]interface{}, len(tags))
+	for _, w := range inline {
+		v, err := decodeValue(w.tag, w.ref, w.ref.Data[:w.ref.DataSize])
+		if err != nil {
 			return nil, err
 		}
+		values[w.tag] = v
 	}
+	for _, w := range outOfLine {
+		data := make([]byte, w.ref.DataSize)
+		if _, err := r.src.ReadAt(data, w.ref.dataOffset()); err != nil {
+			return nil, err
+		}
+		v, err := decodeValue(w.tag, w.ref, data)
+		if err != nil {
+			return nil, err
+		}
+		values[w.tag] = v
+	}
+	return values, nil
+}
 
+// decodeValue decodes data, the raw bytes for tag t's ref x, into a Go value.
+func decodeValue(t Tag, x ref, data []byte) (interface{}, error) {
 	if x.ElemType >= 1024 {
 		// user-defined data structure; return as a slice
 		return data, nil
@@ -170,166 +404,177 @@ func (r *Reader) Value(t Tag) (interface{}, error) {
 	if typ.size == 0 { // missing dataTypes element
 		return nil, errBadType{Tag: t, ref: x}
 	}
-	if x.NElem < 1 {
-		return nil, errBadValue(t)
+	if x.NElem < 0 {
+		return nil, errBadValue{Tag: t, reason: fmt.Sprintf("NElem %d < 0", x.NElem)}
 	}
 	if int(x.NElem)*typ.size > len(data) {
-		return nil, errBadValue(t)
+		return nil, errBadValue{Tag: t, reason: fmt.Sprintf("NElem %d * element size %d exceeds %d bytes of data", x.NElem, typ.size, len(data))}
 	}
 
+	var v interface{}
+	var err error
 	if x.NElem == 1 {
-		return typ.one(data), nil
+		v, err = typ.one(data)
+	} else {
+		v, err = typ.many(int(x.NElem), data)
 	}
-	return typ.many(int(x.NElem), data), nil
+	if err != nil {
+		return nil, errBadValue{Tag: t, reason: err.Error()}
+	}
+	return coerceKnownType(t, v), nil
 }
 
 var dataTypes = [...]struct {
 	name string // debugging and documentation use only for now; the name and comment are taken verbatim from the spec
 	size int
-	one  func([]byte) interface{}
-	many func(int, []byte) interface{}
+	one  func([]byte) (interface{}, error)
+	many func(int, []byte) (interface{}, error)
 }{
 	// Current data types
 	1: {
 		name: "byte", // Unsigned 8-bit integer.
 		size: 1,
-		one:  func(b []byte) interface{} { return b[0] },
-		many: func(n int, b []byte) interface{} {
+		one:  func(b []byte) (interface{}, error) { return b[0], nil },
+		many: func(n int, b []byte) (interface{}, error) {
 			x := make([]byte, n)
 			copy(x, b[:n])
-			return x
+			return x, nil
 		},
 	},
 	2: {
 		name: "char", // 8-bit ASCII character or signed 8-bit integer
 		size: 1,
-		one:  func(b []byte) interface{} { return int8(b[0]) },
-		many: func(n int, b []byte) interface{} {
+		one:  func(b []byte) (interface{}, error) { return int8(b[0]), nil },
+		many: func(n int, b []byte) (interface{}, error) {
 			x := make([]int8, n)
 			for i := range x {
 				x[i] = int8(b[i])
 			}
-			return x
+			return x, nil
 		},
 	},
 	3: {
 		name: "word", // Unsigned 16-bit integer.
 		size: 2,
-		one:  func(b []byte) interface{} { return binary.BigEndian.Uint16(b[:2]) },
-		many: func(n int, b []byte) interface{} {
+		one:  func(b []byte) (interface{}, error) { return binary.BigEndian.Uint16(b[:2]), nil },
+		many: func(n int, b []byte) (interface{}, error) {
 			x := make([]uint16, n)
 			for i := range x {
 				x[i] = binary.BigEndian.Uint16(b[i*2 : i*2+2])
 			}
-			return x
+			return x, nil
 		},
 	},
 	4: {
 		name: "short", // Signed 16-bit integer.
 		size: 2,
-		one:  func(b []byte) interface{} { return int16(binary.BigEndian.Uint16(b[:2])) },
-		many: func(n int, b []byte) interface{} {
+		one:  func(b []byte) (interface{}, error) { return int16(binary.BigEndian.Uint16(b[:2])), nil },
+		many: func(n int, b []byte) (interface{}, error) {
 			x := make([]int16, n)
 			for i := range x {
 				x[i] = int16(binary.BigEndian.Uint16(b[i*2 : i*2+2]))
 			}
-			return x
+			return x, nil
 		},
 	},
 	5: {
 		name: "long", // Signed 32-bit integer.
 		size: 4,
-		one:  func(b []byte) interface{} { return int32(binary.BigEndian.Uint32(b[:4])) },
-		many: func(n int, b []byte) interface{} {
+		one:  func(b []byte) (interface{}, error) { return int32(binary.BigEndian.Uint32(b[:4])), nil },
+		many: func(n int, b []byte) (interface{}, error) {
 			x := make([]int32, n)
 			for i := range x {
 				x[i] = int32(binary.BigEndian.Uint32(b[i*4 : i*4+4]))
 			}
-			return x
+			return x, nil
 		},
 	},
 	7: {
 		name: "float", // 32-bit floating point value.
 		size: 4,
-		one:  func(b []byte) interface{} { return math.Float32frombits(binary.BigEndian.Uint32(b[:4])) },
-		many: func(n int, b []byte) interface{} {
+		one: func(b []byte) (interface{}, error) {
+			return math.Float32frombits(binary.BigEndian.Uint32(b[:4])), nil
+		},
+		many: func(n int, b []byte) (interface{}, error) {
 			x := make([]float32, n)
 			for i := range x {
 				x[i] = math.Float32frombits(binary.BigEndian.Uint32(b[i*4 : i*4+4]))
 			}
-			return x
+			return x, nil
 		},
 	},
 	8: {
 		name: "double", // 64-bit floating point value.
 		size: 8,
-		one:  func(b []byte) interface{} { return math.Float64frombits(binary.BigEndian.Uint64(b[:8])) },
-		many: func(n int, b []byte) interface{} {
+		one: func(b []byte) (interface{}, error) {
+			return math.Float64frombits(binary.BigEndian.Uint64(b[:8])), nil
+		},
+		many: func(n int, b []byte) (interface{}, error) {
 			x := make([]float64, n)
 			for i := range x {
 				x[i] = math.Float64frombits(binary.BigEndian.Uint64(b[i*8 : i*8+8]))
 			}
-			return x
+			return x, nil
 		},
 	},
 	10: {
 		name: "date",
 		size: 4,
-		one:  func(b []byte) interface{} { return parseDate(b) },
-		many: func(n int, b []byte) interface{} {
+		one:  func(b []byte) (interface{}, error) { return parseDate(b), nil },
+		many: func(n int, b []byte) (interface{}, error) {
 			x := make([]time.Time, n)
 			for i := range x {
 				x[i] = parseDate(b[i*4 : i*4+4])
 			}
-			return x
+			return x, nil
 		},
 	},
 	11: {
 		name: "time",
 		size: 4,
-		one:  func(b []byte) interface{} { return parseTime(b) },
-		many: func(n int, b []byte) interface{} {
+		one:  func(b []byte) (interface{}, error) { return parseTime(b), nil },
+		many: func(n int, b []byte) (interface{}, error) {
 			x := make([]time.Time, n)
 			for i := range x {
 				x[i] = parseTime(b[i*4 : i*4+4])
 			}
-			return x
+			return x, nil
 		},
 	},
 	18: {
 		name: "pString", // Pascal string, consisting of a character count (from 0 to 255) in the first byte followed by the 8-bit ASCII characters.
 		size: 1,
-		one: func(b []byte) interface{} {
+		one: func(b []byte) (interface{}, error) {
 			if b[0] != 0 {
-				panic("bad pString len")
+				return nil, fmt.Errorf("pString: length byte %d, want 0", b[0])
 			}
-			return ""
+			return "", nil
 		},
-		many: func(n int, b []byte) interface{} {
+		many: func(n int, b []byte) (interface{}, error) {
+			if len(b) == 0 {
+				return nil, fmt.Errorf("pString: no length byte")
+			}
 			if int(b[0]) != len(b)-1 {
-				panic("bad pString len")
+				return nil, fmt.Errorf("pString: length byte %d, want %d", b[0], len(b)-1)
 			}
-			return string(b[1:])
+			return string(b[1:]), nil
 		},
 	},
 	19: {
 		name: "cString", // C-style string, consisting of a string of 8-bit ASCII characters followed by a null (zero) byte.
 		size: 1,
-		one: func(b []byte) interface{} {
+		one: func(b []byte) (interface{}, error) {
 			if b[0] != 0 {
-				panic("bad cString terminator")
+				return nil, fmt.Errorf("cString: byte 0 is %d, want a null terminator", b[0])
 			}
-			return ""
+			return "", nil
 		},
-		many: func(n int, b []byte) interface{} {
-			var i int
-			for i < len(b) && b[i] != 0 {
-				i++
-			}
-			if i == len(b) {
-				panic("bad cString terminator")
+		many: func(n int, b []byte) (interface{}, error) {
+			i := bytes.IndexByte(b, 0)
+			if i < 0 {
+				return nil, fmt.Errorf("cString: no null terminator in %d bytes", len(b))
 			}
-			return string(b[:i])
+			return string(b[:i]), nil
 		},
 	},
 
@@ -337,13 +582,13 @@ var dataTypes = [...]struct {
 	12: {
 		name: "thumb",
 		size: 10,
-		one:  func(b []byte) interface{} { return parseThumb(b) },
-		many: func(n int, b []byte) interface{} {
+		one:  func(b []byte) (interface{}, error) { return parseThumb(b), nil },
+		many: func(n int, b []byte) (interface{}, error) {
 			x := make([]Thumb, n)
 			for i := range x {
 				x[i] = parseThumb(b[i*10 : i*10+10])
 			}
-			return x
+			return x, nil
 		},
 	},
 }
@@ -0,0 +1,76 @@
+package abif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// buildHeader returns the fixed-size ABIF header (magic, version, root
+// directory entry) for a file whose directory has nElem entries located
+// at dirOffset.
+func buildHeader(t *testing.T, version uint16, nElem int32, dirOffset int32) []byte {
+	t.Helper()
+	var rootRef ref
+	rootRef.ElemType = 1023
+	rootRef.ElemSize = 28
+	rootRef.NElem = nElem
+	binary.BigEndian.PutUint32(rootRef.Data[:], uint32(dirOffset))
+
+	var buf bytes.Buffer
+	buf.WriteString("ABIF")
+	if err := binary.Write(&buf, binary.BigEndian, version); err != nil {
+		t.Fatal(err)
+	}
+	if err := binary.Write(&buf, binary.BigEndian, entry{Tag: NewTag("tdir", 1), Ref: rootRef}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestNewReaderHugeDirectoryRejected checks that a corrupt root
+// directory NElem is rejected with an error instead of crashing the
+// process with an out-of-memory fatal error, with or without Strict.
+func TestNewReaderHugeDirectoryRejected(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		header := buildHeader(t, 101, 1<<30, headerLen)
+		_, err := NewReaderAtOptions(bytes.NewReader(header), int64(len(header)), Options{Strict: strict})
+		if err == nil {
+			t.Fatalf("strict=%v: want error for a 1<<30-entry directory, got nil", strict)
+		}
+	}
+}
+
+// TestNewReaderDirectoryOutOfBounds checks that a directory claiming to
+// extend past the end of a known-size file is rejected.
+func TestNewReaderDirectoryOutOfBounds(t *testing.T) {
+	header := buildHeader(t, 101, 10, headerLen) // claims 10 entries (280 bytes) but file ends right after the header
+	_, err := NewReaderAtOptions(bytes.NewReader(header), int64(len(header)), Options{})
+	if err == nil {
+		t.Fatal("want error for a directory that overruns a known-size file, got nil")
+	}
+}
+
+// TestNewReaderAtOptionsStrict checks that a round-tripped file written
+// by Writer passes strict validation and reads back its PBAS:1 tag.
+func TestNewReaderAtOptionsStrict(t *testing.T) {
+	w := NewWriter()
+	if err := w.SetValue(NewTag("PBAS", 1), "ACGT"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetValue(NewTag("DATA", 9), []int16{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReaderAtOptions(bytes.NewReader(buf.Bytes()), int64(buf.Len()), Options{Strict: true})
+	if err != nil {
+		t.Fatalf("strict validation of a well-formed file failed: %v", err)
+	}
+	if _, err := r.Value(NewTag("PBAS", 1)); err != nil {
+		t.Fatalf("Value(PBAS:1): %v", err)
+	}
+}
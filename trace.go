@@ -0,0 +1,122 @@
+package abif
+
+import (
+	"fmt"
+	"time"
+)
+
+// A Trace is a high-level view of the standard Sanger sequencing fields
+// stored in an ABIF file, built on top of a Reader. It spares callers
+// from knowing the raw 4-char tag vocabulary.
+type Trace struct {
+	r *Reader
+}
+
+// NewTrace returns a Trace view over r.
+func NewTrace(r *Reader) *Trace {
+	return &Trace{r: r}
+}
+
+// Bases returns the basecalled sequence, preferring the basecaller's call
+// (PBAS 2) over the user-edited call (PBAS 1).
+func (t *Trace) Bases() (string, error) {
+	s, err := t.r.StringValue(NewTag("PBAS", 2))
+	if isNotFound(err) {
+		s, err = t.r.StringValue(NewTag("PBAS", 1))
+	}
+	return s, err
+}
+
+// Qualities returns the per-base quality values, preferring the
+// basecaller's call (PCON 2) over the user-edited call (PCON 1).
+func (t *Trace) Qualities() ([]byte, error) {
+	tag := NewTag("PCON", 2)
+	v, err := t.r.Value(tag)
+	if isNotFound(err) {
+		tag = NewTag("PCON", 1)
+		v, err = t.r.Value(tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch b := v.(type) {
+	case []byte:
+		return b, nil
+	case byte:
+		return []byte{b}, nil
+	}
+	return nil, errWrongType{Tag: tag, want: "[]byte", got: v}
+}
+
+// PeakLocations returns the location, in trace data samples, of each
+// called base, preferring the basecaller's call (PLOC 2) over the
+// user-edited call (PLOC 1).
+func (t *Trace) PeakLocations() ([]int16, error) {
+	p, err := t.r.Int16sValue(NewTag("PLOC", 2))
+	if isNotFound(err) {
+		p, err = t.r.Int16sValue(NewTag("PLOC", 1))
+	}
+	return p, err
+}
+
+// TraceData returns the processed trace data (DATA 9-12), one channel
+// per base, keyed by the base each channel corresponds to as given by
+// FWO_'s order.
+func (t *Trace) TraceData() (map[byte][]int16, error) {
+	order, err := t.r.StringValue(NewTag("FWO_", 1))
+	if err != nil {
+		return nil, err
+	}
+	if len(order) != 4 {
+		return nil, fmt.Errorf("FWO_: want 4 bases, got %q", order)
+	}
+
+	data := make(map[byte][]int16, 4)
+	for i, base := range []byte(order) {
+		v, err := t.r.Int16sValue(NewTag("DATA", int32(9+i)))
+		if err != nil {
+			return nil, err
+		}
+		data[base] = v
+	}
+	return data, nil
+}
+
+// Dye returns the name of the dye set used for the run.
+func (t *Trace) Dye() (string, error) {
+	return t.r.StringValue(NewTag("DySN", 1))
+}
+
+// SampleName returns the name of the sequenced sample.
+func (t *Trace) SampleName() (string, error) {
+	return t.r.StringValue(NewTag("SMPL", 1))
+}
+
+// RunStart returns the date and time the run started.
+func (t *Trace) RunStart() (time.Time, error) {
+	return t.runDateTime(1)
+}
+
+// RunEnd returns the date and time the run stopped.
+func (t *Trace) RunEnd() (time.Time, error) {
+	return t.runDateTime(2)
+}
+
+// runDateTime combines the matching RUND (date) and RUNT (time) tags
+// numbered num into a single time.Time.
+func (t *Trace) runDateTime(num int32) (time.Time, error) {
+	d, err := t.r.TimeValue(NewTag("RUND", num))
+	if err != nil {
+		return time.Time{}, err
+	}
+	tm, err := t.r.TimeValue(NewTag("RUNT", num))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(d.Year(), d.Month(), d.Day(), tm.Hour(), tm.Minute(), tm.Second(), tm.Nanosecond(), time.UTC), nil
+}
+
+// Thumbprint returns the file's thumbprint, a quasi-unique identifier.
+func (t *Trace) Thumbprint() (Thumb, error) {
+	return t.r.ThumbValue(NewTag("Thum", 1))
+}
@@ -0,0 +1,34 @@
+package abif
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestQualitiesReportsFallbackTag checks that a type mismatch on the
+// PCON:1 fallback is reported against PCON:1, not the PCON:2 tag that
+// was never actually present.
+func TestQualitiesReportsFallbackTag(t *testing.T) {
+	w := NewWriter()
+	// A string, not a []byte/byte, so decoding PCON:1 as qualities fails.
+	if err := w.SetValue(NewTag("PCON", 1), "oops"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewTrace(r).Qualities()
+	ewt, ok := err.(errWrongType)
+	if !ok {
+		t.Fatalf("Qualities() error = %v, want errWrongType", err)
+	}
+	if want := NewTag("PCON", 1); ewt.Tag != want {
+		t.Errorf("Qualities() error tag = %s, want %s", ewt.Tag, want)
+	}
+}
@@ -0,0 +1,82 @@
+package abif
+
+import "time"
+
+// StringValue reads the value identified by t as a string, returning
+// errWrongType if the tag's value is not a string.
+func (r *Reader) StringValue(t Tag) (string, error) {
+	v, err := r.Value(t)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", errWrongType{Tag: t, want: "string", got: v}
+	}
+	return s, nil
+}
+
+// Int16sValue reads the value identified by t as a []int16, returning
+// errWrongType if the tag's value is not a []int16 or int16. A tag with
+// exactly one element decodes as a bare int16 (see decodeValue); that
+// case is normalized to a one-element slice here.
+func (r *Reader) Int16sValue(t Tag) ([]int16, error) {
+	v, err := r.Value(t)
+	if err != nil {
+		return nil, err
+	}
+	switch x := v.(type) {
+	case []int16:
+		return x, nil
+	case int16:
+		return []int16{x}, nil
+	}
+	return nil, errWrongType{Tag: t, want: "[]int16", got: v}
+}
+
+// Float32sValue reads the value identified by t as a []float32,
+// returning errWrongType if the tag's value is not a []float32 or
+// float32. A tag with exactly one element decodes as a bare float32
+// (see decodeValue); that case is normalized to a one-element slice
+// here.
+func (r *Reader) Float32sValue(t Tag) ([]float32, error) {
+	v, err := r.Value(t)
+	if err != nil {
+		return nil, err
+	}
+	switch x := v.(type) {
+	case []float32:
+		return x, nil
+	case float32:
+		return []float32{x}, nil
+	}
+	return nil, errWrongType{Tag: t, want: "[]float32", got: v}
+}
+
+// TimeValue reads the value identified by t as a time.Time, returning
+// errWrongType if the tag's value is not a time.Time.
+func (r *Reader) TimeValue(t Tag) (time.Time, error) {
+	v, err := r.Value(t)
+	if err != nil {
+		return time.Time{}, err
+	}
+	tt, ok := v.(time.Time)
+	if !ok {
+		return time.Time{}, errWrongType{Tag: t, want: "time.Time", got: v}
+	}
+	return tt, nil
+}
+
+// ThumbValue reads the value identified by t as a Thumb, returning
+// errWrongType if the tag's value is not a Thumb.
+func (r *Reader) ThumbValue(t Tag) (Thumb, error) {
+	v, err := r.Value(t)
+	if err != nil {
+		return Thumb{}, err
+	}
+	th, ok := v.(Thumb)
+	if !ok {
+		return Thumb{}, errWrongType{Tag: t, want: "Thumb", got: v}
+	}
+	return th, nil
+}
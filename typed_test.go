@@ -0,0 +1,42 @@
+package abif
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestSingleElementSliceTags checks that tags with exactly one element
+// -- which decodeValue decodes as a bare scalar, not a one-element
+// slice -- still read correctly through the slice-typed accessors and
+// through known-tag string coercion.
+func TestSingleElementSliceTags(t *testing.T) {
+	w := NewWriter()
+	if err := w.SetValue(NewTag("PLOC", 1), []int16{5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetValue(NewTag("DATA", 9), []float32{1.5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetValue(NewTag("PBAS", 1), []int8{'A'}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := r.Int16sValue(NewTag("PLOC", 1)); err != nil || len(got) != 1 || got[0] != 5 {
+		t.Errorf("Int16sValue(PLOC:1) = %v, %v, want []int16{5}, nil", got, err)
+	}
+	if got, err := r.Float32sValue(NewTag("DATA", 9)); err != nil || len(got) != 1 || got[0] != 1.5 {
+		t.Errorf("Float32sValue(DATA:9) = %v, %v, want []float32{1.5}, nil", got, err)
+	}
+	if got, err := r.StringValue(NewTag("PBAS", 1)); err != nil || got != "A" {
+		t.Errorf("StringValue(PBAS:1) = %q, %v, want \"A\", nil", got, err)
+	}
+}
@@ -0,0 +1,103 @@
+package abif
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// recordingReaderAt is an io.ReaderAt over data that records the offset
+// of every ReadAt call except those at offset 0 (the header read). The
+// directory read is recorded too, so the test clears offsets before
+// calling Values.
+type recordingReaderAt struct {
+	data    []byte
+	offsets []int64
+}
+
+func (r *recordingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off > 0 {
+		r.offsets = append(r.offsets, off)
+	}
+	n := copy(p, r.data[off:])
+	return n, nil
+}
+
+// TestValuesAndAllValues checks that AllValues and Values return the
+// correct value for every tag -- a mix of inline and out-of-line,
+// requested out of offset order -- and that the out-of-line reads
+// Values issues are sorted by offset regardless of the order tags are
+// requested in.
+func TestValuesAndAllValues(t *testing.T) {
+	w := NewWriter()
+	want := map[Tag]interface{}{
+		NewTag("byte", 1): uint8(9),                       // inline
+		NewTag("PBAS", 1): "ACGTACGTACGTACGTACGTACGTACGT", // out-of-line
+		NewTag("PCON", 1): []byte{10, 20, 30, 40, 50},     // out-of-line
+		NewTag("PLOC", 1): []int16{100, 200, 300},         // out-of-line
+	}
+	for tag, v := range want {
+		if err := w.SetValue(tag, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	src := &recordingReaderAt{data: buf.Bytes()}
+	r, err := NewReaderAt(src, int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := r.AllValues()
+	if err != nil {
+		t.Fatalf("AllValues: %v", err)
+	}
+	if len(all) != len(want) {
+		t.Fatalf("AllValues returned %d tags, want %d", len(all), len(want))
+	}
+	for tag, wantV := range want {
+		if !valuesEqual(all[tag], wantV) {
+			t.Errorf("AllValues()[%s] = %#v, want %#v", tag, all[tag], wantV)
+		}
+	}
+
+	// Request the tags in reverse-offset order; Values should still
+	// return the right value for each, and should still have issued its
+	// out-of-line reads in increasing offset order.
+	tags := r.Tags()
+	sort.Slice(tags, func(i, j int) bool { return tags[i].String() > tags[j].String() })
+	src.offsets = nil
+	got, err := r.Values(tags)
+	if err != nil {
+		t.Fatalf("Values: %v", err)
+	}
+	if !reflect.DeepEqual(mapKeys(got), mapKeys(all)) {
+		t.Errorf("Values and AllValues disagree on which tags are present: %v vs %v", mapKeys(got), mapKeys(all))
+	}
+	for tag, wantV := range want {
+		if !valuesEqual(got[tag], wantV) {
+			t.Errorf("Values()[%s] = %#v, want %#v", tag, got[tag], wantV)
+		}
+	}
+
+	for i := 1; i < len(src.offsets); i++ {
+		if src.offsets[i] < src.offsets[i-1] {
+			t.Errorf("Values issued out-of-line reads out of offset order: %v", src.offsets)
+			break
+		}
+	}
+}
+
+func mapKeys(m map[Tag]interface{}) []Tag {
+	tags := make([]Tag, 0, len(m))
+	for t := range m {
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].String() < tags[j].String() })
+	return tags
+}
@@ -0,0 +1,293 @@
+package abif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// writerVersion is the ABIF version number written by Writer.
+// Per the spec, version/100 must equal 1.
+const writerVersion = 101
+
+// A Writer assembles tag values into a valid ABIF file.
+//
+// Call SetValue (or SetRaw, for user-defined data) for each tag, then
+// WriteTo to serialize the header, directory, and data section.
+//
+// The zero value is not usable; use NewWriter.
+type Writer struct {
+	entries map[Tag]writerEntry
+}
+
+type writerEntry struct {
+	elemType int16
+	nElem    int32
+	data     []byte // NElem big-endian-encoded elements, concatenated
+}
+
+// NewWriter returns an empty Writer.
+func NewWriter() *Writer {
+	return &Writer{entries: make(map[Tag]writerEntry)}
+}
+
+// SetValue sets the value for tag t, replacing any previous value.
+//
+// v must be one of the types that Value returns: uint8, int8, uint16,
+// int16, int32, float32, float64, string, Thumb, []byte, or a slice of
+// any of the other listed types except string. Strings are encoded as a Pascal string
+// if they fit (len <= 255), otherwise as a C string.
+//
+// time.Time is deliberately not accepted here: Reader's "date" and
+// "time" element types both decode to time.Time, and a zero-valued or
+// sentinel date is indistinguishable from a time of day by value alone
+// (see parseDate and parseTime), so guessing which one was meant would
+// silently mis-encode real ABIF data such as an unset RUND. Use
+// SetDateValue or SetTimeValue instead, which say which one is meant.
+//
+// For user-defined data (ElemType >= 1024), use SetRaw instead; there is
+// no way to infer a custom element type from a Go value alone.
+func (w *Writer) SetValue(t Tag, v interface{}) error {
+	elemType, nElem, data, err := encodeValue(v)
+	if err != nil {
+		return fmt.Errorf("tag %s: %w", t, err)
+	}
+	w.entries[t] = writerEntry{elemType: elemType, nElem: nElem, data: data}
+	return nil
+}
+
+// SetDateValue sets tag t to v, encoded as a calendar date (the "date"
+// element type), using v's year, month, and day. Unlike SetValue, this
+// never needs to guess whether v means a date or a time of day, so it
+// round-trips correctly even for a zero-valued or sentinel date such as
+// an unset RUND.
+func (w *Writer) SetDateValue(t Tag, v time.Time) error {
+	w.entries[t] = writerEntry{elemType: 10, nElem: 1, data: encodeDate(v)}
+	return nil
+}
+
+// SetTimeValue sets tag t to v, encoded as a time of day (the "time"
+// element type), using v's hour, minute, second, and fractional second.
+func (w *Writer) SetTimeValue(t Tag, v time.Time) error {
+	w.entries[t] = writerEntry{elemType: 11, nElem: 1, data: encodeTime(v)}
+	return nil
+}
+
+// SetRaw sets tag t to data verbatim, tagged with a user-defined element
+// type (>= 1024). Reader returns such data as []byte, uninterpreted.
+func (w *Writer) SetRaw(t Tag, elemType int16, data []byte) error {
+	if elemType < 1024 {
+		return fmt.Errorf("tag %s: SetRaw requires a user-defined element type (>= 1024), got %d", t, elemType)
+	}
+	buf := make([]byte, len(data))
+	copy(buf, data)
+	w.entries[t] = writerEntry{elemType: elemType, nElem: int32(len(buf)), data: buf}
+	return nil
+}
+
+// WriteTo writes a complete ABIF file to dst.
+func (w *Writer) WriteTo(dst io.Writer) (int64, error) {
+	tags := make([]Tag, 0, len(w.entries))
+	for t := range w.entries {
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Name != tags[j].Name {
+			return string(tags[i].Name[:]) < string(tags[j].Name[:])
+		}
+		return tags[i].Num < tags[j].Num
+	})
+
+	const headerSize = 6 // magic (4) + version (2)
+	const entrySize = 28 // Tag (8) + ref (20)
+	dirOffset := int64(headerSize + entrySize)
+	dataOffset := dirOffset + int64(len(tags))*entrySize
+
+	var dir bytes.Buffer
+	var data bytes.Buffer
+	for _, t := range tags {
+		we := w.entries[t]
+		var r ref
+		r.ElemType = we.elemType
+		r.ElemSize = elemSizeFor(we.elemType)
+		r.NElem = we.nElem
+		r.DataSize = int32(len(we.data))
+		if len(we.data) <= 4 {
+			copy(r.Data[:], we.data)
+		} else {
+			binary.BigEndian.PutUint32(r.Data[:], uint32(dataOffset+int64(data.Len())))
+			data.Write(we.data)
+		}
+		if err := binary.Write(&dir, binary.BigEndian, entry{Tag: t, Ref: r}); err != nil {
+			return 0, err
+		}
+	}
+
+	var rootRef ref
+	rootRef.ElemType = 1023 // root directory element type, per spec
+	rootRef.ElemSize = entrySize
+	rootRef.NElem = int32(len(tags))
+	rootRef.DataSize = int32(dir.Len())
+	binary.BigEndian.PutUint32(rootRef.Data[:], uint32(dirOffset))
+
+	var out bytes.Buffer
+	out.WriteString("ABIF")
+	if err := binary.Write(&out, binary.BigEndian, uint16(writerVersion)); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(&out, binary.BigEndian, entry{Tag: NewTag("tdir", 1), Ref: rootRef}); err != nil {
+		return 0, err
+	}
+	out.Write(dir.Bytes())
+	out.Write(data.Bytes())
+
+	n, err := dst.Write(out.Bytes())
+	return int64(n), err
+}
+
+// encodeValue encodes v as it would appear in an ABIF data section,
+// returning its element type, element count, and big-endian-encoded
+// bytes.
+func encodeValue(v interface{}) (elemType int16, nElem int32, data []byte, err error) {
+	switch x := v.(type) {
+	case uint8:
+		return 1, 1, []byte{x}, nil
+	case []byte: // also covers []uint8
+		buf := make([]byte, len(x))
+		copy(buf, x)
+		return 1, int32(len(x)), buf, nil
+	case int8:
+		return 2, 1, []byte{byte(x)}, nil
+	case []int8:
+		buf := make([]byte, len(x))
+		for i, e := range x {
+			buf[i] = byte(e)
+		}
+		return 2, int32(len(x)), buf, nil
+	case uint16:
+		buf := make([]byte, 2)
+		binary.BigEndian.PutUint16(buf, x)
+		return 3, 1, buf, nil
+	case []uint16:
+		buf := make([]byte, len(x)*2)
+		for i, e := range x {
+			binary.BigEndian.PutUint16(buf[i*2:], e)
+		}
+		return 3, int32(len(x)), buf, nil
+	case int16:
+		buf := make([]byte, 2)
+		binary.BigEndian.PutUint16(buf, uint16(x))
+		return 4, 1, buf, nil
+	case []int16:
+		buf := make([]byte, len(x)*2)
+		for i, e := range x {
+			binary.BigEndian.PutUint16(buf[i*2:], uint16(e))
+		}
+		return 4, int32(len(x)), buf, nil
+	case int32:
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, uint32(x))
+		return 5, 1, buf, nil
+	case []int32:
+		buf := make([]byte, len(x)*4)
+		for i, e := range x {
+			binary.BigEndian.PutUint32(buf[i*4:], uint32(e))
+		}
+		return 5, int32(len(x)), buf, nil
+	case float32:
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, math.Float32bits(x))
+		return 7, 1, buf, nil
+	case []float32:
+		buf := make([]byte, len(x)*4)
+		for i, e := range x {
+			binary.BigEndian.PutUint32(buf[i*4:], math.Float32bits(e))
+		}
+		return 7, int32(len(x)), buf, nil
+	case float64:
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, math.Float64bits(x))
+		return 8, 1, buf, nil
+	case []float64:
+		buf := make([]byte, len(x)*8)
+		for i, e := range x {
+			binary.BigEndian.PutUint64(buf[i*8:], math.Float64bits(e))
+		}
+		return 8, int32(len(x)), buf, nil
+	case string:
+		return encodeString(x)
+	case Thumb:
+		return 12, 1, encodeThumb(x), nil
+	case []Thumb:
+		buf := make([]byte, len(x)*10)
+		for i, e := range x {
+			copy(buf[i*10:], encodeThumb(e))
+		}
+		return 12, int32(len(x)), buf, nil
+	default:
+		return 0, 0, nil, fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+// encodeString encodes s as a Pascal string if it fits (a length byte
+// can only hold 0-255), otherwise as a C string. A C string is
+// null-terminated, so a string longer than 255 bytes that also contains
+// an embedded NUL cannot be represented either way and is rejected: a
+// cString reader would silently truncate it at the embedded NUL.
+func encodeString(s string) (elemType int16, nElem int32, data []byte, err error) {
+	if len(s) <= 255 {
+		buf := make([]byte, 1+len(s))
+		buf[0] = byte(len(s))
+		copy(buf[1:], s)
+		return 18, int32(len(buf)), buf, nil
+	}
+	if bytes.IndexByte([]byte(s), 0) >= 0 {
+		return 0, 0, nil, fmt.Errorf("string of %d bytes contains an embedded NUL byte and is too long (> 255 bytes) to encode as a Pascal string instead", len(s))
+	}
+	buf := make([]byte, len(s)+1)
+	copy(buf, s)
+	return 19, int32(len(buf)), buf, nil
+}
+
+func encodeDate(t time.Time) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint16(b, uint16(int16(t.Year())))
+	b[2] = byte(t.Month())
+	b[3] = byte(t.Day())
+	return b
+}
+
+func encodeTime(t time.Time) []byte {
+	const centisecond = int(time.Millisecond * 100)
+	return []byte{
+		byte(t.Hour()),
+		byte(t.Minute()),
+		byte(t.Second()),
+		byte(t.Nanosecond() / centisecond),
+	}
+}
+
+// elemSizeFor returns the ElemSize to write for elemType: the size of
+// one element of a known type, or 1 for user-defined (>= 1024) data,
+// which Value reads as a single opaque byte blob regardless of ElemSize.
+func elemSizeFor(elemType int16) int16 {
+	if int(elemType) < len(dataTypes) {
+		if size := dataTypes[elemType].size; size != 0 {
+			return int16(size)
+		}
+	}
+	return 1
+}
+
+func encodeThumb(th Thumb) []byte {
+	b := make([]byte, 10)
+	binary.BigEndian.PutUint32(b, uint32(th.D))
+	binary.BigEndian.PutUint32(b[4:], uint32(th.U))
+	b[8] = th.C
+	b[9] = th.N
+	return b
+}
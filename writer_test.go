@@ -0,0 +1,180 @@
+package abif
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// TestWriterRoundTrip checks that each scalar value type Writer.SetValue
+// accepts, plus an []int16 slice, survives a write/read round trip,
+// including an inline value (<=4 bytes), an out-of-line value, a date
+// set with SetDateValue, and user-defined raw data.
+func TestWriterRoundTrip(t *testing.T) {
+	runStart := time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)
+
+	w := NewWriter()
+	values := map[Tag]interface{}{
+		NewTag("byte", 1): uint8(7),
+		NewTag("char", 1): int8(-3),
+		NewTag("word", 1): uint16(40000),
+		NewTag("shrt", 1): int16(-1234),
+		NewTag("long", 1): int32(-123456789),
+		NewTag("flot", 1): float32(1.5),
+		NewTag("dbl_", 1): float64(2.5),
+		NewTag("pstr", 1): "short string",
+		NewTag("cstr", 1): string(bytes.Repeat([]byte("x"), 300)), // forces a C string
+		NewTag("PLOC", 1): []int16{1, 2, 3, 4, 5},
+		NewTag("thum", 1): Thumb{D: 1, U: 2, C: 3, N: 4},
+		NewTag("PBAS", 1): "ACGTACGT",
+	}
+	for tag, v := range values {
+		if err := w.SetValue(tag, v); err != nil {
+			t.Fatalf("SetValue(%s, %v): %v", tag, v, err)
+		}
+	}
+	if err := w.SetRaw(NewTag("user", 1), 1024, []byte{1, 2, 3}); err != nil {
+		t.Fatalf("SetRaw: %v", err)
+	}
+	if err := w.SetDateValue(NewTag("date", 1), runStart); err != nil {
+		t.Fatalf("SetDateValue: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+
+	for tag, want := range values {
+		got, err := r.Value(tag)
+		if err != nil {
+			t.Errorf("Value(%s): %v", tag, err)
+			continue
+		}
+		if !valuesEqual(got, want) {
+			t.Errorf("Value(%s) = %#v, want %#v", tag, got, want)
+		}
+	}
+
+	raw, err := r.Value(NewTag("user", 1))
+	if err != nil {
+		t.Fatalf("Value(user:1): %v", err)
+	}
+	if !bytes.Equal(raw.([]byte), []byte{1, 2, 3}) {
+		t.Errorf("Value(user:1) = %v, want [1 2 3]", raw)
+	}
+
+	date, err := r.Value(NewTag("date", 1))
+	if err != nil {
+		t.Fatalf("Value(date:1): %v", err)
+	}
+	if !valuesEqual(date, runStart) {
+		t.Errorf("Value(date:1) = %#v, want %#v", date, runStart)
+	}
+}
+
+// TestSetDateValueSentinelDate checks that an all-zero "unset date"
+// sentinel -- whose Year() is negative once normalized by time.Date --
+// still round-trips as a date, not a time of day, since SetDateValue
+// doesn't infer the element type from the value.
+func TestSetDateValueSentinelDate(t *testing.T) {
+	sentinel := time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC)
+
+	w := NewWriter()
+	tag := NewTag("RUND", 2)
+	if err := w.SetDateValue(tag, sentinel); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.TimeValue(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := parseDate(encodeDate(sentinel))
+	if !got.Equal(want) {
+		t.Errorf("Value(%s) = %v, want %v (a date, not a time of day)", tag, got, want)
+	}
+}
+
+// TestSetValueRejectsTimeTime checks that SetValue rejects time.Time,
+// since it has no way to tell a date from a time of day by value alone.
+func TestSetValueRejectsTimeTime(t *testing.T) {
+	w := NewWriter()
+	if err := w.SetValue(NewTag("RUND", 1), time.Now()); err == nil {
+		t.Fatal("SetValue(time.Time) = nil error, want an error directing callers to SetDateValue/SetTimeValue")
+	}
+}
+
+// TestSetValueEmptySlice checks that an empty slice round-trips as an
+// empty slice rather than being rejected by Value as a malformed NElem.
+func TestSetValueEmptySlice(t *testing.T) {
+	w := NewWriter()
+	tag := NewTag("PLOC", 1)
+	if err := w.SetValue(tag, []int16{}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Int16sValue(tag)
+	if err != nil {
+		t.Fatalf("Int16sValue: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Int16sValue(%s) = %v, want an empty slice", tag, got)
+	}
+}
+
+// TestSetValueRejectsStringWithEmbeddedNUL checks that a long string
+// with an embedded NUL byte is rejected rather than silently
+// truncated by a cString reader.
+func TestSetValueRejectsStringWithEmbeddedNUL(t *testing.T) {
+	s := string(bytes.Repeat([]byte("x"), 100)) + "\x00" + string(bytes.Repeat([]byte("y"), 200))
+	w := NewWriter()
+	if err := w.SetValue(NewTag("pstr", 1), s); err == nil {
+		t.Fatal("SetValue(long string with embedded NUL) = nil error, want an error")
+	}
+}
+
+func valuesEqual(got, want interface{}) bool {
+	switch w := want.(type) {
+	case []int16:
+		g, ok := got.([]int16)
+		if !ok || len(g) != len(w) {
+			return false
+		}
+		for i := range g {
+			if g[i] != w[i] {
+				return false
+			}
+		}
+		return true
+	case []byte:
+		g, ok := got.([]byte)
+		return ok && bytes.Equal(g, w)
+	case time.Time:
+		g, ok := got.(time.Time)
+		return ok && g.Equal(w)
+	default:
+		return got == want
+	}
+}